@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// adminAPI exposes the Store over HTTP so operators can inspect and replay
+// dropped or bounced messages. Every route requires a bearer token.
+type adminAPI struct {
+	store  Store
+	mailer Mailer
+	token  string
+}
+
+func newAdminAPI(store Store, mailer Mailer, token string) *adminAPI {
+	return &adminAPI{store: store, mailer: mailer, token: token}
+}
+
+func (a *adminAPI) register(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/messages", a.authenticated(a.handleList))
+	mux.HandleFunc("/admin/messages/", a.authenticated(a.handleMessage))
+}
+
+func (a *adminAPI) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("Authorization")
+		want := "Bearer " + a.token
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (a *adminAPI) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	msgs, err := a.store.List(r.Context())
+	if err != nil {
+		log.Printf("admin: failed to list messages err=%v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, msgs)
+}
+
+// handleMessage dispatches /admin/messages/{id} and /admin/messages/{id}/retry.
+func (a *adminAPI) handleMessage(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/messages/"), "/")
+	parts := strings.Split(rest, "/")
+
+	id := parts[0]
+	if id == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "retry" {
+		a.handleRetry(w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		a.handleGet(w, r, id)
+	case http.MethodDelete:
+		a.handleDelete(w, r, id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *adminAPI) handleGet(w http.ResponseWriter, r *http.Request, id string) {
+	msg, err := a.store.Get(r.Context(), id)
+	if err != nil {
+		log.Printf("admin: failed to get message id=%#v err=%v", id, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, msg)
+}
+
+func (a *adminAPI) handleDelete(w http.ResponseWriter, r *http.Request, id string) {
+	err := a.store.Delete(r.Context(), id)
+	if err != nil {
+		log.Printf("admin: failed to delete message id=%#v err=%v", id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminAPI) handleRetry(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	msg, err := a.store.Get(r.Context(), id)
+	if err != nil {
+		log.Printf("admin: failed to get message for retry id=%#v err=%v", id, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	mr := &mailReq{
+		ID:       msg.ID,
+		Name:     msg.Name,
+		Email:    msg.Email,
+		Message:  msg.Message,
+		ClientIP: msg.ClientIP,
+	}
+
+	err = a.mailer.Send(r.Context(), mr)
+	if err != nil {
+		log.Printf("admin: retry failed id=%#v err=%v", id, err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("admin: failed to encode response err=%v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAdminServer(t *testing.T) (*httptest.Server, *fakeStore, *fakeMailer) {
+	store := newFakeStore()
+	fm := &fakeMailer{done: make(chan struct{})}
+	api := newAdminAPI(store, fm, "s3cr3t")
+
+	mux := http.NewServeMux()
+	api.register(mux)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv, store, fm
+}
+
+func adminRequest(t *testing.T, method, url, token string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	require.Nil(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	return resp
+}
+
+func TestAdminAPIRequiresBearerToken(t *testing.T) {
+	srv, _, _ := newTestAdminServer(t)
+
+	resp := adminRequest(t, http.MethodGet, srv.URL+"/admin/messages", "")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = adminRequest(t, http.MethodGet, srv.URL+"/admin/messages", "wrong")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestAdminAPIGetAndDelete(t *testing.T) {
+	srv, store, _ := newTestAdminServer(t)
+	store.messages["msg-1"] = &StoredMessage{ID: "msg-1", Email: "hans@example.org", Status: StatusDeadLetter}
+
+	resp := adminRequest(t, http.MethodGet, srv.URL+"/admin/messages/msg-1", "s3cr3t")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = adminRequest(t, http.MethodDelete, srv.URL+"/admin/messages/msg-1", "s3cr3t")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = adminRequest(t, http.MethodGet, srv.URL+"/admin/messages/msg-1", "s3cr3t")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAdminAPIRetry(t *testing.T) {
+	srv, store, fm := newTestAdminServer(t)
+	store.messages["msg-2"] = &StoredMessage{ID: "msg-2", Name: "hans", Email: "hans@example.org", Message: "hi", Status: StatusDeadLetter}
+
+	resp := adminRequest(t, http.MethodPost, srv.URL+"/admin/messages/msg-2/retry", "s3cr3t")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, 1, fm.calls)
+}
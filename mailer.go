@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"log"
+	"os/exec"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Mailer delivers a mailReq, rendering it with the configured subject and
+// message templates along the way.
+type Mailer interface {
+	Send(ctx context.Context, mr *mailReq) error
+}
+
+// mailConfig carries the fields shared by every Mailer implementation: who a
+// message is from/to and how to render it.
+type mailConfig struct {
+	From            string
+	To              string
+	SubjectTemplate *template.Template
+	MessageTemplate *htmlTemplate.Template
+}
+
+func (mc *mailConfig) render(mr *mailReq) (subject, message string, err error) {
+	subject, err = mr.makeSubject(mc.SubjectTemplate)
+	if err != nil {
+		return "", "", err
+	}
+
+	message, err = mr.makeMessage(mc.MessageTemplate)
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, message, nil
+}
+
+type smtpConfig struct {
+	Host string
+	Port int
+	User string
+	Pass string
+}
+
+// SMTPMailer sends mail by dialing an upstream SMTP relay, the original
+// delivery path of contact.
+type SMTPMailer struct {
+	Config mailConfig
+	SMTP   smtpConfig
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, mr *mailReq) error {
+	subject, message, err := m.Config.render(mr)
+	if err != nil {
+		return err
+	}
+
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.Config.From)
+	msg.SetHeader("To", m.Config.To)
+	msg.SetHeader("Subject", subject)
+	msg.SetBody("text/html", message)
+
+	d := gomail.NewDialer(m.SMTP.Host, m.SMTP.Port, m.SMTP.User, m.SMTP.Pass)
+	return d.DialAndSend(msg)
+}
+
+// SendmailMailer hands a message to the local `sendmail -t` binary, useful on
+// hosts that already run an MTA.
+type SendmailMailer struct {
+	Config mailConfig
+	Path   string
+}
+
+func (m *SendmailMailer) Send(ctx context.Context, mr *mailReq) error {
+	subject, message, err := m.Config.render(mr)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.Config.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.Config.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&buf, "Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	buf.WriteString(message)
+
+	path := m.Path
+	if path == "" {
+		path = "/usr/sbin/sendmail"
+	}
+
+	cmd := exec.CommandContext(ctx, path, "-t")
+	cmd.Stdin = &buf
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sendmail failed err=%w out=%#v", err, string(out))
+	}
+
+	return nil
+}
+
+// NullMailer logs the message it would have sent instead of sending it.
+// Useful for local development and in tests that don't want to depend on a
+// live SMTP server.
+type NullMailer struct {
+	Config mailConfig
+}
+
+func (m *NullMailer) Send(ctx context.Context, mr *mailReq) error {
+	subject, message, err := m.Config.render(mr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("null-mailer: from=%#v to=%#v subject=%#v message=%#v", m.Config.From, m.Config.To, subject, message)
+	return nil
+}
+
+// QueueMailer wraps another Mailer with a buffered queue of worker
+// goroutines, decoupling callers of Send from SMTP latency. Failed sends are
+// retried with exponential backoff, up to MaxRetries times, before being
+// dropped and logged.
+type QueueMailer struct {
+	inner       Mailer
+	store       Store
+	jobs        chan *mailReq
+	maxRetries  int
+	baseBackoff time.Duration
+
+	wg sync.WaitGroup
+}
+
+// NewQueueMailer builds a QueueMailer. When store is non-nil, Send persists a
+// StoredMessage before enqueueing mr, so a crash between the 202 Accepted
+// response and a worker dequeuing the job still leaves a trace.
+func NewQueueMailer(inner Mailer, store Store, workers, bufferSize, maxRetries int, baseBackoff time.Duration) *QueueMailer {
+	qm := &QueueMailer{
+		inner:       inner,
+		store:       store,
+		jobs:        make(chan *mailReq, bufferSize),
+		maxRetries:  maxRetries,
+		baseBackoff: baseBackoff,
+	}
+
+	for i := 0; i < workers; i++ {
+		qm.wg.Add(1)
+		go qm.work()
+	}
+
+	return qm
+}
+
+// Send persists a fresh record for mr, if configured with a store and no
+// record for mr.ID already exists, before enqueueing it for asynchronous
+// delivery, so the record exists even if the process crashes before a
+// worker dequeues the job. An existing record (e.g. a dead-lettered message
+// being retried) is left untouched here; StoreMailer, further down the
+// chain, owns updating attempts/status once a worker actually sends it.
+// Send returns an error only if the queue is full.
+func (qm *QueueMailer) Send(ctx context.Context, mr *mailReq) error {
+	if qm.store != nil {
+		_, err := qm.store.Get(ctx, mr.ID)
+		if errors.Is(err, errMessageNotFound) {
+			if err := qm.store.Save(ctx, newStoredMessage(mr)); err != nil {
+				log.Printf("queue-mailer: failed to persist message id=%#v err=%v", mr.ID, err)
+			}
+		} else if err != nil {
+			log.Printf("queue-mailer: failed to look up message id=%#v err=%v", mr.ID, err)
+		}
+	}
+
+	select {
+	case qm.jobs <- mr:
+		return nil
+	default:
+		return fmt.Errorf("mail queue is full")
+	}
+}
+
+func (qm *QueueMailer) work() {
+	defer qm.wg.Done()
+
+	for mr := range qm.jobs {
+		backoff := qm.baseBackoff
+		var err error
+
+		for attempt := 0; attempt <= qm.maxRetries; attempt++ {
+			err = qm.inner.Send(context.Background(), mr)
+			if err == nil {
+				break
+			}
+
+			log.Printf("queue-mailer: failed to send mail attempt=%v err=%v", attempt, err)
+			if attempt == qm.maxRetries {
+				break
+			}
+
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		if err != nil {
+			log.Printf("queue-mailer: giving up on mail to=%#v err=%v", mr.Email, err)
+		}
+	}
+}
+
+// Shutdown stops accepting new sends, waits for queued work to drain, and
+// returns once all workers have exited or ctx is done.
+func (qm *QueueMailer) Shutdown(ctx context.Context) error {
+	close(qm.jobs)
+
+	done := make(chan struct{})
+	go func() {
+		qm.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StoreMailer wraps another Mailer, persisting a StoredMessage for every
+// send attempt so operators can see delivered, dead-lettered, and in-flight
+// messages after a crash. Place it inside a QueueMailer so every retry
+// attempt is recorded.
+type StoreMailer struct {
+	inner       Mailer
+	store       Store
+	maxAttempts int
+}
+
+func NewStoreMailer(inner Mailer, store Store, maxAttempts int) *StoreMailer {
+	return &StoreMailer{inner: inner, store: store, maxAttempts: maxAttempts}
+}
+
+func (m *StoreMailer) Send(ctx context.Context, mr *mailReq) error {
+	rec, err := m.store.Get(ctx, mr.ID)
+	if errors.Is(err, errMessageNotFound) {
+		rec = newStoredMessage(mr)
+	} else if err != nil {
+		return fmt.Errorf("failed to look up stored message id=%#v err=%w", mr.ID, err)
+	}
+	rec.Attempts++
+
+	sendErr := m.inner.Send(ctx, mr)
+	if sendErr == nil {
+		rec.Status = StatusDelivered
+		rec.LastError = ""
+		rec.DeliveredAt = time.Now()
+	} else {
+		rec.LastError = sendErr.Error()
+		rec.Status = StatusReceived
+		if m.maxAttempts > 0 && rec.Attempts >= m.maxAttempts {
+			rec.Status = StatusDeadLetter
+		}
+	}
+
+	err = m.store.Save(ctx, rec)
+	if err != nil {
+		log.Printf("store-mailer: failed to persist message id=%#v err=%v", mr.ID, err)
+	}
+
+	return sendErr
+}
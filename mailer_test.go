@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testMailConfig(t *testing.T) mailConfig {
+	subject, err := template.New("subject").Parse("contact from {{.Name}}")
+	require.Nil(t, err)
+
+	message, err := htmlTemplate.New("message").Parse("Name: {{.Name}}\nEmail: {{.Email}}\n{{.Message}}")
+	require.Nil(t, err)
+
+	return mailConfig{
+		From:            "from@example.org",
+		To:              "to@example.org",
+		SubjectTemplate: subject,
+		MessageTemplate: message,
+	}
+}
+
+func TestNullMailerSend(t *testing.T) {
+	m := &NullMailer{Config: testMailConfig(t)}
+	mr := &mailReq{Name: "hans", Email: "hans@example.org", Message: "hi there"}
+
+	err := m.Send(context.Background(), mr)
+	require.Nil(t, err)
+}
+
+func TestSendmailMailerSend(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	script := filepath.Join(dir, "sendmail")
+
+	err := os.WriteFile(script, []byte(fmt.Sprintf("#!/bin/sh\ncat > %s\n", out)), 0755)
+	require.Nil(t, err)
+
+	m := &SendmailMailer{Config: testMailConfig(t), Path: script}
+	mr := &mailReq{Name: "hans", Email: "hans@example.org", Message: "hi there"}
+
+	err = m.Send(context.Background(), mr)
+	require.Nil(t, err)
+
+	got, err := os.ReadFile(out)
+	require.Nil(t, err)
+	require.Contains(t, string(got), "Subject: contact from hans")
+	require.Contains(t, string(got), "Email: hans@example.org")
+}
+
+type fakeMailer struct {
+	failures int
+	calls    int
+	done     chan struct{}
+}
+
+func (m *fakeMailer) Send(ctx context.Context, mr *mailReq) error {
+	m.calls++
+	if m.calls <= m.failures {
+		return errors.New("temporary failure")
+	}
+	close(m.done)
+	return nil
+}
+
+func TestQueueMailerRetries(t *testing.T) {
+	fm := &fakeMailer{failures: 2, done: make(chan struct{})}
+	qm := NewQueueMailer(fm, nil, 1, 1, 3, time.Millisecond)
+
+	err := qm.Send(context.Background(), &mailReq{Name: "hans", Email: "hans@example.org", Message: "hi"})
+	require.Nil(t, err)
+
+	select {
+	case <-fm.done:
+	case <-time.After(time.Second):
+		t.Fatal("mail was not delivered after retries")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, qm.Shutdown(ctx))
+	require.Equal(t, 3, fm.calls)
+}
+
+func TestQueueMailerFullQueueReturnsError(t *testing.T) {
+	fm := &fakeMailer{done: make(chan struct{})}
+	qm := &QueueMailer{inner: fm, jobs: make(chan *mailReq)}
+
+	err := qm.Send(context.Background(), &mailReq{Name: "hans"})
+	require.NotNil(t, err)
+}
+
+func TestQueueMailerPersistsBeforeDequeue(t *testing.T) {
+	store := newFakeStore()
+	fm := &fakeMailer{done: make(chan struct{})}
+	qm := NewQueueMailer(fm, store, 1, 1, 3, time.Millisecond)
+
+	err := qm.Send(context.Background(), &mailReq{ID: "msg-3", Name: "hans", Email: "hans@example.org", Message: "hi"})
+	require.Nil(t, err)
+
+	rec, err := store.Get(context.Background(), "msg-3")
+	require.Nil(t, err)
+	require.Equal(t, StatusReceived, rec.Status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, qm.Shutdown(ctx))
+}
+
+func TestQueueMailerRetryDoesNotClobberExistingRecord(t *testing.T) {
+	store := newFakeStore()
+	receivedAt := time.Now().Add(-48 * time.Hour)
+	store.messages["msg-4"] = &StoredMessage{
+		ID:         "msg-4",
+		Name:       "hans",
+		Email:      "hans@example.org",
+		Message:    "hi",
+		ReceivedAt: receivedAt,
+		Status:     StatusDeadLetter,
+		Attempts:   5,
+		LastError:  "temporary failure",
+	}
+
+	fm := &fakeMailer{done: make(chan struct{})}
+	qm := &QueueMailer{inner: fm, store: store, jobs: make(chan *mailReq, 1)}
+
+	err := qm.Send(context.Background(), &mailReq{ID: "msg-4", Name: "hans", Email: "hans@example.org", Message: "hi"})
+	require.Nil(t, err)
+
+	rec, err := store.Get(context.Background(), "msg-4")
+	require.Nil(t, err)
+	require.Equal(t, StatusDeadLetter, rec.Status)
+	require.Equal(t, 5, rec.Attempts)
+	require.Equal(t, "temporary failure", rec.LastError)
+	require.True(t, rec.ReceivedAt.Equal(receivedAt))
+}
@@ -2,60 +2,199 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	htmlTemplate "html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
+	"syscall"
 	"text/template"
 	"time"
 
-	"gopkg.in/gomail.v2"
 	"gopkg.in/yaml.v2"
 )
 
 type server struct {
-	addr       string
-	mux        *http.ServeMux
-	reqCache   *cache
-	mailConfig *mailConfig
+	addr        string
+	mux         *http.ServeMux
+	limiter     *requestLimiter
+	spam        *SpamPipeline
+	mailer      Mailer
+	mailAsync   bool
+	to          string
+	admin       *adminAPI
+	smtpIngress *smtpIngress
+	heuristics  *HeuristicCheck
+
+	httpServer *http.Server
 }
 
 func newServer(cfg *Config) (*server, error) {
-	c, err := newCache(cfg.RequestTimeLimit, cfg.RequestTimeLimit/10, 10)
+	var store Store
+	if cfg.Admin.Token != "" {
+		s, err := newStore(cfg.Store)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create store err=%w", err)
+		}
+		store = s
+	}
+
+	mailer, err := newMailer(cfg.Email, store, cfg.Store.MaxAttempts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request cache err=%w", err)
+		return nil, fmt.Errorf("failed to create mailer err=%w", err)
+	}
+
+	spam, heuristics, err := newSpamPipelineFromConfig(cfg.Spam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spam pipeline err=%w", err)
 	}
 
 	s := &server{
-		addr:     cfg.Addr,
-		mux:      http.NewServeMux(),
-		reqCache: c,
-		mailConfig: &mailConfig{
-			To:              cfg.Email.From,
-			From:            cfg.Email.From,
-			SubjectTemplate: cfg.Email.templateSubject,
-			MessageTemplate: cfg.Email.templateMessage,
+		addr:       cfg.Addr,
+		mux:        http.NewServeMux(),
+		limiter:    newRequestLimiter(cfg.RateLimit),
+		spam:       spam,
+		mailer:     mailer,
+		mailAsync:  cfg.Email.Queue.Enabled,
+		to:         cfg.Email.From,
+		heuristics: heuristics,
+	}
+
+	if store != nil {
+		s.admin = newAdminAPI(store, mailer, cfg.Admin.Token)
+	}
+
+	if cfg.SMTPServer.Listen != "" {
+		ing, err := newSMTPIngress(cfg.SMTPServer, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create smtp-server err=%w", err)
+		}
+		s.smtpIngress = ing
+	}
+
+	return s, nil
+}
+
+// newStore builds the Store for the configured backend.
+func newStore(cfg ConfigStore) (Store, error) {
+	switch cfg.Backend {
+	case "", "bolt":
+		return newBoltStore(cfg.Path)
+	case "sqlite":
+		return newSQLiteStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown store.backend=%#v", cfg.Backend)
+	}
+}
+
+// newMailer builds the Mailer for the configured backend, wrapping it with a
+// StoreMailer (when store is set) and then a QueueMailer (when email.queue
+// is enabled), in that order, so every retry attempt is persisted. store is
+// also handed to the QueueMailer directly, so it can persist the message
+// synchronously at enqueue time rather than only once a worker picks it up.
+func newMailer(cfg ConfigEmail, store Store, maxAttempts int) (Mailer, error) {
+	mc := mailConfig{
+		From:            cfg.From,
+		To:              cfg.From,
+		SubjectTemplate: cfg.templateSubject,
+		MessageTemplate: cfg.templateMessage,
+	}
+
+	var m Mailer
+	switch cfg.Backend {
+	case "", "smtp":
+		m = &SMTPMailer{
+			Config: mc,
 			SMTP: smtpConfig{
-				Host: cfg.Email.SMTP.Host,
-				Port: cfg.Email.SMTP.Port,
-				User: cfg.Email.SMTP.User,
-				Pass: cfg.Email.SMTP.Pass,
+				Host: cfg.SMTP.Host,
+				Port: cfg.SMTP.Port,
+				User: cfg.SMTP.User,
+				Pass: cfg.SMTP.Pass,
 			},
-		},
+		}
+	case "sendmail":
+		m = &SendmailMailer{Config: mc, Path: cfg.SendmailPath}
+	case "null":
+		m = &NullMailer{Config: mc}
+	default:
+		return nil, fmt.Errorf("unknown email.backend=%#v", cfg.Backend)
 	}
-	return s, nil
+
+	if store != nil {
+		m = NewStoreMailer(m, store, maxAttempts)
+	}
+
+	if cfg.Queue.Enabled {
+		workers, bufferSize, maxRetries := cfg.Queue.Workers, cfg.Queue.BufferSize, cfg.Queue.MaxRetries
+		if workers <= 0 {
+			workers = 1
+		}
+		if bufferSize <= 0 {
+			bufferSize = 100
+		}
+		baseBackoff := cfg.Queue.BaseBackoff
+		if baseBackoff <= 0 {
+			baseBackoff = time.Second
+		}
+		m = NewQueueMailer(m, store, workers, bufferSize, maxRetries, baseBackoff)
+	}
+
+	return m, nil
+}
+
+// newSpamPipelineFromConfig builds the spam pipeline, in order: honeypot,
+// captcha, heuristics, and finally the legacy suffix check (enabled unless
+// explicitly disabled, to keep existing deployments behaving as before). It
+// also returns the heuristics check on its own: the SMTP ingress reuses just
+// that stage, since honeypot/captcha need an HTTP form and the suffix check
+// needs mr.Check, which no inbound SMTP message has.
+func newSpamPipelineFromConfig(cfg ConfigSpam) (*SpamPipeline, *HeuristicCheck, error) {
+	var checks []SpamCheck
+	var heuristics *HeuristicCheck
+
+	if cfg.Honeypot.Enabled {
+		checks = append(checks, &HoneypotCheck{Field: cfg.Honeypot.Field})
+	}
+
+	if cfg.Captcha.Enabled {
+		checks = append(checks, &CaptchaCheck{
+			Field:     cfg.Captcha.Field,
+			VerifyURL: cfg.Captcha.VerifyURL,
+			Secret:    cfg.Captcha.Secret,
+		})
+	}
+
+	if cfg.Heuristics.Enabled {
+		hc, err := newHeuristicCheck(cfg.Heuristics)
+		if err != nil {
+			return nil, nil, err
+		}
+		checks = append(checks, hc)
+		heuristics = hc
+	}
+
+	if !cfg.SuffixCheck.Disabled {
+		checks = append(checks, &SuffixCheck{})
+	}
+
+	return newSpamPipeline(checks...), heuristics, nil
 }
 
 func (s *server) setupRouting() {
 	s.mux.HandleFunc("/mail", s.handleMailRequest)
+	if s.admin != nil {
+		s.admin.register(s.mux)
+	}
 }
 
 func (s *server) start() error {
-	srv := http.Server{
+	s.httpServer = &http.Server{
 		Addr:           s.addr,
 		Handler:        s.mux,
 		ReadTimeout:    10 * time.Second,
@@ -63,15 +202,91 @@ func (s *server) start() error {
 		MaxHeaderBytes: 1 << 20,
 	}
 	s.setupRouting()
+
+	if s.smtpIngress != nil {
+		go func() {
+			err := s.smtpIngress.start()
+			if err != nil {
+				log.Printf("smtp-server ingress failed err=%v", err)
+			}
+		}()
+	}
+
 	log.Printf("starting server addr=%v", s.addr)
-	return srv.ListenAndServe()
+	err := s.httpServer.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// mailerShutdowner is implemented by Mailers that need to drain in-flight
+// work before the process exits, such as QueueMailer.
+type mailerShutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdown stops accepting new connections on the HTTP and SMTP ingresses
+// and, if the mailer supports it, waits for queued sends to drain before ctx
+// is done.
+func (s *server) shutdown(ctx context.Context) error {
+	if s.smtpIngress != nil {
+		if err := s.smtpIngress.Close(); err != nil {
+			log.Printf("failed to close smtp-server ingress err=%v", err)
+		}
+	}
+
+	if s.httpServer != nil {
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shut down http server err=%w", err)
+		}
+	}
+
+	if ms, ok := s.mailer.(mailerShutdowner); ok {
+		if err := ms.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to drain mailer queue err=%w", err)
+		}
+	}
+
+	return nil
+}
+
+// handleInboundMail routes a message received on the SMTP ingress through the
+// same rate limiting, spam heuristics, and delivery path as the HTTP contact
+// form. Honeypot/captcha and the suffix check don't apply here: there's no
+// form to hide a field in, and no mr.Check for an inbound SMTP message to
+// carry.
+func (s *server) handleInboundMail(mr *mailReq) error {
+	if ok, _ := s.limiter.Allow(mr.Email, mr.ClientIP); !ok {
+		return fmt.Errorf("rejecting inbound mail request email=%#v, rate limited", mr.Email)
+	}
+
+	if s.heuristics != nil {
+		_, reject, reason, err := s.heuristics.Check(context.Background(), nil, mr)
+		if err != nil {
+			return fmt.Errorf("spam check failed err=%w", err)
+		}
+		if reject {
+			return fmt.Errorf("rejecting inbound mail request email=%#v, reason=%#v", mr.Email, reason)
+		}
+	}
+
+	err := s.mailer.Send(context.Background(), mr)
+	if err != nil {
+		return fmt.Errorf("failed to send mail err=%w", err)
+	}
+
+	log.Printf("handled inbound mail request to %#v", s.to)
+	return nil
 }
 
 type mailReq struct {
-	Name    string
-	Email   string
-	Message string
-	Check   string
+	ID       string
+	Name     string
+	Email    string
+	Message  string
+	Check    string
+	ClientIP string
 }
 
 func newMailRequest(name, email, message, check string) (*mailReq, error) {
@@ -84,16 +299,14 @@ func newMailRequest(name, email, message, check string) (*mailReq, error) {
 	if message == "" {
 		return nil, fmt.Errorf("missing required message")
 	}
-	if check == "" {
-		return nil, fmt.Errorf("missing required check")
-	}
 
-	err := validateMessage(message, check)
+	id, err := newMessageID()
 	if err != nil {
-		return nil, fmt.Errorf("check failed err=%v", err)
+		return nil, fmt.Errorf("failed to create mail request err=%w", err)
 	}
 
 	result := &mailReq{
+		ID:      id,
 		Name:    name,
 		Email:   email,
 		Message: message,
@@ -104,6 +317,10 @@ func newMailRequest(name, email, message, check string) (*mailReq, error) {
 }
 
 func validateMessage(msg, check string) error {
+	if len(check) > len(msg) {
+		return fmt.Errorf("message suffix=%v does not match the given check=%#v", msg, check)
+	}
+
 	sfx := msg[len(msg)-len(check):]
 	if check != sfx {
 		return fmt.Errorf("message suffix=%v does not match the given check=%#v", sfx, check)
@@ -130,6 +347,16 @@ func (r *mailReq) makeSubject(t *template.Template) (string, error) {
 	return buf.String(), nil
 }
 
+// clientIP extracts the request's remote address without its port, falling
+// back to the raw value if it can't be split.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (s *server) handleMailRequest(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -138,12 +365,12 @@ func (s *server) handleMailRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.reqCache.Exists(r.Form.Get("email")) {
-		log.Printf("rejecting request")
+	email := r.Form.Get("email")
+	if ok, retryAfter := s.limiter.Allow(email, clientIP(r)); !ok {
+		log.Printf("rejecting request email=%#v", email)
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", retryAfter.Seconds()))
 		w.WriteHeader(http.StatusTooManyRequests)
 		return
-	} else {
-		s.reqCache.Add(r.Form.Get("email"))
 	}
 
 	mr, err := newMailRequest(
@@ -157,51 +384,32 @@ func (s *server) handleMailRequest(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	mr.ClientIP = clientIP(r)
 
-	err = sendMail(s.mailConfig, mr)
+	score, reject, reason, err := s.spam.Run(r.Context(), r, mr)
 	if err != nil {
-		log.Printf("failed to send mail err=%v", err)
+		log.Printf("spam check failed err=%v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-
-	log.Printf("handled mail request to %#v", s.mailConfig.To)
-}
-
-type mailConfig struct {
-	From            string
-	To              string
-	SubjectTemplate *template.Template
-	MessageTemplate *htmlTemplate.Template
-	SMTP            smtpConfig
-}
-
-type smtpConfig struct {
-	Host string
-	Port int
-	User string
-	Pass string
-}
-
-func sendMail(mc *mailConfig, mr *mailReq) error {
-	subject, err := mr.makeSubject(mc.SubjectTemplate)
-	if err != nil {
-		return err
+	if reject {
+		log.Printf("rejecting spam request reason=%#v score=%v", reason, score)
+		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
-	message, err := mr.makeMessage(mc.MessageTemplate)
+	err = s.mailer.Send(r.Context(), mr)
 	if err != nil {
-		return err
+		log.Printf("failed to send mail err=%v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", mc.From)
-	m.SetHeader("To", mc.From)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", message)
+	if s.mailAsync {
+		w.WriteHeader(http.StatusAccepted)
+	}
 
-	d := gomail.NewDialer(mc.SMTP.Host, mc.SMTP.Port, mc.SMTP.User, mc.SMTP.Pass)
-	return d.DialAndSend(m)
+	log.Printf("handled mail request to %#v", s.to)
 }
 
 type ContactFlags struct {
@@ -270,16 +478,71 @@ func fileExists(path string) bool {
 }
 
 type Config struct {
-	Addr             string        `yaml:"addr"`
-	Email            ConfigEmail   `yaml:"email"`
-	RequestTimeLimit time.Duration `yaml:"request-time-limit"`
+	Addr       string           `yaml:"addr"`
+	Email      ConfigEmail      `yaml:"email"`
+	SMTPServer smtpServerConfig `yaml:"smtp-server"`
+	RateLimit  ConfigRateLimit  `yaml:"rate-limit"`
+	Spam       ConfigSpam       `yaml:"spam"`
+	Store      ConfigStore      `yaml:"store"`
+	Admin      ConfigAdmin      `yaml:"admin"`
+}
+
+// ConfigStore is the `store` section of Config: where delivery attempts are
+// persisted. It's only used once admin.token is set.
+type ConfigStore struct {
+	Backend     string `yaml:"backend"` // "bolt" (default) or "sqlite"
+	Path        string `yaml:"path"`
+	MaxAttempts int    `yaml:"max-attempts"`
+}
+
+// ConfigAdmin is the `admin` section of Config. Setting a token enables the
+// Store and the authenticated /admin/messages API.
+type ConfigAdmin struct {
+	Token string `yaml:"token"`
+}
+
+// ConfigSpam is the `spam` section of Config: a chain of optional checks run
+// in handleMailRequest before a message is handed to the Mailer.
+type ConfigSpam struct {
+	Honeypot    ConfigHoneypot    `yaml:"honeypot"`
+	Captcha     ConfigCaptcha     `yaml:"captcha"`
+	Heuristics  ConfigHeuristics  `yaml:"heuristics"`
+	SuffixCheck ConfigSuffixCheck `yaml:"suffix-check"`
+}
+
+type ConfigHoneypot struct {
+	Enabled bool   `yaml:"enabled"`
+	Field   string `yaml:"field"`
+}
+
+type ConfigCaptcha struct {
+	Enabled   bool   `yaml:"enabled"`
+	Field     string `yaml:"field"`
+	VerifyURL string `yaml:"verify-url"`
+	Secret    string `yaml:"secret"`
+}
+
+type ConfigHeuristics struct {
+	Enabled      bool     `yaml:"enabled"`
+	MaxLinks     int      `yaml:"max-links"`
+	MaxCapsRatio float64  `yaml:"max-caps-ratio"`
+	Blocklist    []string `yaml:"blocklist"`
+}
+
+// ConfigSuffixCheck guards the original suffix-matching check. It defaults to
+// enabled so existing configs keep their current behavior.
+type ConfigSuffixCheck struct {
+	Disabled bool `yaml:"disabled"`
 }
 
 type ConfigEmail struct {
-	From            string     `yaml:"from"`
-	SubjectTemplate string     `yaml:"subject-template"`
-	MessageTemplate string     `yaml:"message-template"`
-	SMTP            ConfigSMTP `yaml:"smtp"`
+	From            string      `yaml:"from"`
+	SubjectTemplate string      `yaml:"subject-template"`
+	MessageTemplate string      `yaml:"message-template"`
+	SMTP            ConfigSMTP  `yaml:"smtp"`
+	Backend         string      `yaml:"backend"`
+	SendmailPath    string      `yaml:"sendmail-path"`
+	Queue           ConfigQueue `yaml:"queue"`
 
 	templateSubject *template.Template
 	templateMessage *htmlTemplate.Template
@@ -292,6 +555,16 @@ type ConfigSMTP struct {
 	Pass string `yaml:"pass"`
 }
 
+// ConfigQueue configures the optional QueueMailer wrapper, which decouples
+// handleMailRequest from SMTP latency.
+type ConfigQueue struct {
+	Enabled     bool          `yaml:"enabled"`
+	Workers     int           `yaml:"workers"`
+	BufferSize  int           `yaml:"buffer-size"`
+	MaxRetries  int           `yaml:"max-retries"`
+	BaseBackoff time.Duration `yaml:"base-backoff"`
+}
+
 func readConfig(fp string) (*Config, error) {
 	bt, err := os.ReadFile(fp)
 	if err != nil {
@@ -330,20 +603,22 @@ func readConfig(fp string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse email.message-template err=%w", err)
 	}
 
-	if cf.Email.SMTP.Host == "" {
-		return nil, fmt.Errorf("config is missing email.smtp.host")
-	}
+	if cf.Email.Backend == "" || cf.Email.Backend == "smtp" {
+		if cf.Email.SMTP.Host == "" {
+			return nil, fmt.Errorf("config is missing email.smtp.host")
+		}
 
-	if cf.Email.SMTP.Port == 0 {
-		return nil, fmt.Errorf("config is missing email.smtp.port")
-	}
+		if cf.Email.SMTP.Port == 0 {
+			return nil, fmt.Errorf("config is missing email.smtp.port")
+		}
 
-	if cf.Email.SMTP.User == "" {
-		return nil, fmt.Errorf("config is missing email.smtp.user")
-	}
+		if cf.Email.SMTP.User == "" {
+			return nil, fmt.Errorf("config is missing email.smtp.user")
+		}
 
-	if cf.Email.SMTP.Pass == "" {
-		return nil, fmt.Errorf("config is missing email.smtp.pass")
+		if cf.Email.SMTP.Pass == "" {
+			return nil, fmt.Errorf("config is missing email.smtp.pass")
+		}
 	}
 
 	return &cf, err
@@ -391,9 +666,29 @@ func main() {
 		log.Fatalf("failed to create server err=%v", err)
 	}
 
-	err = srv.start()
-	if err != nil {
-		log.Fatalf("server failed err=%v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.start()
+	}()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			log.Fatalf("server failed err=%v", err)
+		}
+	case <-ctx.Done():
+		stop()
+		log.Printf("shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := srv.shutdown(shutdownCtx); err != nil {
+			log.Fatalf("failed to shut down cleanly err=%v", err)
+		}
 	}
 }
 
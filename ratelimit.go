@@ -0,0 +1,193 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter is a token-bucket rate limiter keyed by an arbitrary string (an
+// email address, a client IP, or a fixed key for a global limit). Idle keys
+// are periodically garbage collected, mirroring the reaper pattern cache
+// used to use for its TTL.
+type Limiter struct {
+	sync.Mutex
+
+	Rate  rate.Limit
+	Burst int
+	TTL   time.Duration
+
+	stop    bool
+	entries map[string]*limiterEntry
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newLimiter(r rate.Limit, burst int, ttl, reapInterval time.Duration) *Limiter {
+	l := &Limiter{
+		Rate:    r,
+		Burst:   burst,
+		TTL:     ttl,
+		entries: make(map[string]*limiterEntry),
+	}
+	l.startReaper(reapInterval)
+	return l
+}
+
+func (l *Limiter) startReaper(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			if l.stop {
+				return
+			}
+
+			toReap := []string{}
+			l.Lock()
+			cutOff := time.Now().Add(-l.TTL)
+			for k, e := range l.entries {
+				if e.lastSeen.Before(cutOff) {
+					toReap = append(toReap, k)
+				}
+			}
+			for _, k := range toReap {
+				delete(l.entries, k)
+			}
+			l.Unlock()
+		}
+	}()
+}
+
+func (l *Limiter) Destroy() {
+	l.stop = true
+}
+
+func (l *Limiter) Len() int {
+	l.Lock()
+	defer l.Unlock()
+	return len(l.entries)
+}
+
+// Allow reports whether a request for key is allowed under the token bucket.
+// When it isn't, retryAfter is the duration the caller should wait before
+// trying again.
+func (l *Limiter) Allow(key string) (allowed bool, retryAfter time.Duration) {
+	l.Lock()
+	e, ok := l.entries[key]
+	if !ok {
+		e = &limiterEntry{limiter: rate.NewLimiter(l.Rate, l.Burst)}
+		l.entries[key] = e
+	}
+	e.lastSeen = time.Now()
+	lim := e.limiter
+	l.Unlock()
+
+	res := lim.Reserve()
+	if !res.OK() {
+		return false, 0
+	}
+
+	if delay := res.Delay(); delay > 0 {
+		res.Cancel()
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// ConfigRate configures a single token bucket: tokens are added at Rate per
+// second, up to a maximum of Burst.
+type ConfigRate struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// ConfigRateLimit is the `rate-limit` section of Config.
+type ConfigRateLimit struct {
+	PerEmail ConfigRate `yaml:"per-email"`
+	PerIP    ConfigRate `yaml:"per-ip"`
+	Global   ConfigRate `yaml:"global"`
+}
+
+// requestLimiter applies a global, per-email, and per-IP token bucket to
+// every request, so a flood from many distinct emails can't drown the
+// configured Mailer.
+type requestLimiter struct {
+	global   *Limiter
+	perEmail *Limiter
+	perIP    *Limiter
+}
+
+func newRequestLimiter(cfg ConfigRateLimit) *requestLimiter {
+	return &requestLimiter{
+		global:   limiterFromConfig(cfg.Global, defaultGlobalRate, defaultGlobalBurst),
+		perEmail: limiterFromConfig(cfg.PerEmail, defaultRate, defaultBurst),
+		perIP:    limiterFromConfig(cfg.PerIP, defaultRate, defaultBurst),
+	}
+}
+
+// defaultRate and defaultBurst are used for any per-email/per-ip rate-limit
+// section left unconfigured: at most one request per key per minute,
+// matching the conservative, block-the-obvious-repeat behavior of the
+// dedupe cache this limiter replaces.
+const defaultRate = rate.Limit(1.0 / 60)
+const defaultBurst = 1
+
+// defaultGlobalRate and defaultGlobalBurst are used when rate-limit.global
+// is left unconfigured. The global bucket caps the whole site rather than a
+// single email or IP, so it needs a far more generous default than
+// defaultRate: reusing defaultRate here would cap every deployment that
+// doesn't explicitly configure rate-limit.global at one submission per
+// minute, site-wide, which is a severe regression for any site with more
+// than trivial traffic.
+const defaultGlobalRate = rate.Limit(5)
+const defaultGlobalBurst = 20
+
+func limiterFromConfig(cfg ConfigRate, fallbackRate rate.Limit, fallbackBurst int) *Limiter {
+	r := rate.Limit(cfg.Rate)
+	burst := cfg.Burst
+	if cfg.Rate <= 0 {
+		r = fallbackRate
+	}
+	if burst <= 0 {
+		burst = fallbackBurst
+	}
+	return newLimiter(r, burst, time.Hour, time.Minute)
+}
+
+const globalLimiterKey = "global"
+
+// Allow applies the global, per-email, and per-IP limits in turn, stopping at
+// the first limit that rejects the request and returning that limit's
+// retryAfter. Limits checked before the rejecting one have already taken a
+// token.
+func (rl *requestLimiter) Allow(email, ip string) (allowed bool, retryAfter time.Duration) {
+	ok, d := rl.global.Allow(globalLimiterKey)
+	if !ok {
+		return false, d
+	}
+
+	ok, d = rl.perEmail.Allow(email)
+	if !ok {
+		return false, d
+	}
+
+	if ip != "" {
+		ok, d = rl.perIP.Allow(ip)
+		if !ok {
+			return false, d
+		}
+	}
+
+	return true, 0
+}
+
+func (rl *requestLimiter) Destroy() {
+	rl.global.Destroy()
+	rl.perEmail.Destroy()
+	rl.perIP.Destroy()
+}
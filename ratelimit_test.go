@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestLimiterBurst(t *testing.T) {
+	target := newLimiter(rate.Limit(1), 2, time.Minute, time.Millisecond)
+	defer target.Destroy()
+
+	ok, _ := target.Allow("hans")
+	require.True(t, ok)
+	ok, _ = target.Allow("hans")
+	require.True(t, ok)
+
+	ok, retryAfter := target.Allow("hans")
+	require.False(t, ok)
+	require.Greater(t, retryAfter, time.Duration(0))
+}
+
+func TestLimiterPerKeyIsolation(t *testing.T) {
+	target := newLimiter(rate.Limit(1), 1, time.Minute, time.Millisecond)
+	defer target.Destroy()
+
+	ok, _ := target.Allow("hans")
+	require.True(t, ok)
+
+	ok, _ = target.Allow("schmitt")
+	require.True(t, ok, "a different key should have its own bucket")
+
+	ok, _ = target.Allow("hans")
+	require.False(t, ok)
+}
+
+func TestLimiterIdleEntriesAreReaped(t *testing.T) {
+	ttl := 10 * time.Millisecond
+	reap := time.Millisecond
+	target := newLimiter(rate.Limit(1), 1, ttl, reap)
+	defer target.Destroy()
+
+	target.Allow("hans")
+	require.Equal(t, 1, target.Len())
+
+	time.Sleep(ttl + 2*reap)
+	require.Equal(t, 0, target.Len(), "idle entries should be reaped")
+}
+
+func TestRequestLimiterPerIPIsolation(t *testing.T) {
+	rl := newRequestLimiter(ConfigRateLimit{
+		PerEmail: ConfigRate{Rate: 100, Burst: 100},
+		PerIP:    ConfigRate{Rate: 1, Burst: 1},
+		Global:   ConfigRate{Rate: 100, Burst: 100},
+	})
+	defer rl.Destroy()
+
+	ok, _ := rl.Allow("hans@example.org", "10.0.0.1")
+	require.True(t, ok)
+
+	ok, _ = rl.Allow("schmitt@example.org", "10.0.0.2")
+	require.True(t, ok, "a different client IP should have its own bucket")
+
+	ok, retryAfter := rl.Allow("hans@example.org", "10.0.0.1")
+	require.False(t, ok)
+	require.Greater(t, retryAfter, time.Duration(0))
+}
+
+func TestRequestLimiterDefaultGlobalDoesNotCapDistinctVisitors(t *testing.T) {
+	rl := newRequestLimiter(ConfigRateLimit{})
+	defer rl.Destroy()
+
+	ok, _ := rl.Allow("hans@example.org", "10.0.0.1")
+	require.True(t, ok)
+
+	ok, _ = rl.Allow("schmitt@example.org", "10.0.0.2")
+	require.True(t, ok, "an unconfigured global bucket should not cap the whole site at one request per minute")
+}
+
+func TestRequestLimiterGlobalCap(t *testing.T) {
+	rl := newRequestLimiter(ConfigRateLimit{
+		PerEmail: ConfigRate{Rate: 100, Burst: 100},
+		PerIP:    ConfigRate{Rate: 100, Burst: 100},
+		Global:   ConfigRate{Rate: 1, Burst: 1},
+	})
+	defer rl.Destroy()
+
+	ok, _ := rl.Allow("hans@example.org", "10.0.0.1")
+	require.True(t, ok)
+
+	ok, retryAfter := rl.Allow("schmitt@example.org", "10.0.0.2")
+	require.False(t, ok, "distinct emails should still share the global cap")
+	require.Greater(t, retryAfter, time.Duration(0))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/mail"
+	"strings"
+
+	smtp "github.com/emersion/go-smtp"
+)
+
+type smtpServerConfig struct {
+	Listen            string `yaml:"listen"`
+	Domain            string `yaml:"domain"`
+	AddrPrefix        string `yaml:"addr-prefix"`
+	MaxMessageBytes   int    `yaml:"max-message-bytes"`
+	MaxRecipients     int    `yaml:"max-recipients"`
+	AllowInsecureAuth bool   `yaml:"allow-insecure-auth"`
+	TLSCert           string `yaml:"tls-cert"`
+	TLSKey            string `yaml:"tls-key"`
+}
+
+// smtpIngress runs an SMTP submission listener that feeds accepted messages
+// into the same pipeline as the HTTP contact form.
+type smtpIngress struct {
+	cfg    smtpServerConfig
+	srv    *server
+	server *smtp.Server
+}
+
+func newSMTPIngress(cfg smtpServerConfig, s *server) (*smtpIngress, error) {
+	if cfg.Domain == "" {
+		return nil, fmt.Errorf("smtp-server is missing domain")
+	}
+
+	ing := &smtpIngress{cfg: cfg, srv: s}
+
+	sm := smtp.NewServer(&smtpBackend{ingress: ing})
+	sm.Addr = cfg.Listen
+	sm.Domain = cfg.Domain
+	sm.MaxMessageBytes = cfg.MaxMessageBytes
+	sm.MaxRecipients = cfg.MaxRecipients
+	sm.AllowInsecureAuth = cfg.AllowInsecureAuth
+
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load smtp-server tls certificate err=%w", err)
+		}
+		sm.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	ing.server = sm
+	return ing, nil
+}
+
+func (ing *smtpIngress) start() error {
+	log.Printf("starting smtp ingress addr=%v domain=%v addr-prefix=%#v", ing.cfg.Listen, ing.cfg.Domain, ing.cfg.AddrPrefix)
+	return ing.server.ListenAndServe()
+}
+
+func (ing *smtpIngress) Close() error {
+	return ing.server.Close()
+}
+
+type smtpBackend struct {
+	ingress *smtpIngress
+}
+
+func (b *smtpBackend) Login(state *smtp.ConnectionState, username, password string) (smtp.Session, error) {
+	return nil, smtp.ErrAuthUnsupported
+}
+
+func (b *smtpBackend) AnonymousLogin(state *smtp.ConnectionState) (smtp.Session, error) {
+	return &smtpSession{ingress: b.ingress, clientIP: smtpClientIP(state.RemoteAddr)}, nil
+}
+
+// smtpClientIP extracts the peer's address without its port, mirroring
+// clientIP for HTTP requests, so inbound mail is subject to the same per-IP
+// rate limiting as the web form.
+func smtpClientIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+type smtpSession struct {
+	ingress  *smtpIngress
+	from     string
+	clientIP string
+}
+
+func (s *smtpSession) Mail(from string, opts smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+func (s *smtpSession) Rcpt(to string) error {
+	prefix := s.ingress.cfg.AddrPrefix
+	if prefix != "" && !strings.HasPrefix(to, prefix) {
+		return fmt.Errorf("mailbox unavailable for rcpt=%#v", to)
+	}
+	return nil
+}
+
+func (s *smtpSession) Data(r io.Reader) error {
+	mr, err := parseInboundMail(r)
+	if err != nil {
+		return fmt.Errorf("failed to parse inbound mail err=%w", err)
+	}
+	mr.ClientIP = s.clientIP
+
+	err = s.ingress.srv.handleInboundMail(mr)
+	if err != nil {
+		return fmt.Errorf("failed to handle inbound mail err=%w", err)
+	}
+
+	return nil
+}
+
+func (s *smtpSession) Reset() {
+	s.from = ""
+}
+
+func (s *smtpSession) Logout() error {
+	return nil
+}
+
+// parseInboundMail reads an RFC5322 message and extracts the fields needed to
+// feed it into the same pipeline as a submitted contact form.
+func parseInboundMail(r io.Reader) (*mailReq, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message err=%w", err)
+	}
+
+	from, err := m.Header.AddressList("From")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse from header err=%w", err)
+	}
+	if len(from) == 0 {
+		return nil, fmt.Errorf("message is missing a from address")
+	}
+
+	body, err := io.ReadAll(m.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message body err=%w", err)
+	}
+
+	name := from[0].Name
+	if name == "" {
+		name = from[0].Address
+	}
+
+	id, err := newMessageID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail request err=%w", err)
+	}
+
+	return &mailReq{
+		ID:      id,
+		Name:    name,
+		Email:   from[0].Address,
+		Message: strings.TrimSpace(string(body)),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SpamCheck is a single stage of the spam pipeline. score contributes to the
+// request's overall spam score; reject, when true, stops the pipeline and
+// fails the request outright with reason as the explanation.
+type SpamCheck interface {
+	Check(ctx context.Context, r *http.Request, mr *mailReq) (score float64, reject bool, reason string, err error)
+}
+
+// SpamPipeline runs a configured chain of SpamChecks in order, stopping at
+// the first rejection or error.
+type SpamPipeline struct {
+	checks []SpamCheck
+}
+
+func newSpamPipeline(checks ...SpamCheck) *SpamPipeline {
+	return &SpamPipeline{checks: checks}
+}
+
+func (p *SpamPipeline) Run(ctx context.Context, r *http.Request, mr *mailReq) (score float64, reject bool, reason string, err error) {
+	for _, c := range p.checks {
+		s, rej, rsn, err := c.Check(ctx, r, mr)
+		if err != nil {
+			return score, false, "", fmt.Errorf("spam check failed err=%w", err)
+		}
+
+		score += s
+		if rej {
+			return score, true, rsn, nil
+		}
+	}
+
+	return score, false, "", nil
+}
+
+// HoneypotCheck rejects requests where a hidden form field, which real
+// visitors never fill in, is non-empty.
+type HoneypotCheck struct {
+	Field string
+}
+
+func (c *HoneypotCheck) Check(ctx context.Context, r *http.Request, mr *mailReq) (float64, bool, string, error) {
+	if r.FormValue(c.Field) != "" {
+		return 0, true, "honeypot field was filled in", nil
+	}
+	return 0, false, "", nil
+}
+
+// CaptchaCheck verifies a captcha token (hCaptcha/Turnstile/reCAPTCHA
+// compatible) by POSTing it along with a shared secret to VerifyURL.
+type CaptchaCheck struct {
+	Field     string
+	VerifyURL string
+	Secret    string
+	Client    *http.Client
+}
+
+type captchaVerifyResponse struct {
+	Success bool `json:"success"`
+}
+
+func (c *CaptchaCheck) Check(ctx context.Context, r *http.Request, mr *mailReq) (float64, bool, string, error) {
+	token := r.FormValue(c.Field)
+	if token == "" {
+		return 0, true, "missing captcha token", nil
+	}
+
+	form := url.Values{}
+	form.Set("secret", c.Secret)
+	form.Set("response", token)
+	if ip := clientIP(r); ip != "" {
+		form.Set("remoteip", ip)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.VerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, false, "", fmt.Errorf("failed to build captcha verify request err=%w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false, "", fmt.Errorf("failed to reach captcha verify url err=%w", err)
+	}
+	defer resp.Body.Close()
+
+	var result captchaVerifyResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return 0, false, "", fmt.Errorf("failed to decode captcha verify response err=%w", err)
+	}
+
+	if !result.Success {
+		return 0, true, "captcha verification failed", nil
+	}
+
+	return 0, false, "", nil
+}
+
+// HeuristicCheck scores a message on link count, all-caps ratio, and a
+// configurable regex blocklist.
+type HeuristicCheck struct {
+	MaxLinks     int
+	MaxCapsRatio float64
+	Blocklist    []*regexp.Regexp
+}
+
+func newHeuristicCheck(cfg ConfigHeuristics) (*HeuristicCheck, error) {
+	blocklist := make([]*regexp.Regexp, 0, len(cfg.Blocklist))
+	for _, pattern := range cfg.Blocklist {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile spam.heuristics.blocklist pattern=%#v err=%w", pattern, err)
+		}
+		blocklist = append(blocklist, re)
+	}
+
+	return &HeuristicCheck{
+		MaxLinks:     cfg.MaxLinks,
+		MaxCapsRatio: cfg.MaxCapsRatio,
+		Blocklist:    blocklist,
+	}, nil
+}
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+func (c *HeuristicCheck) Check(ctx context.Context, r *http.Request, mr *mailReq) (float64, bool, string, error) {
+	var score float64
+
+	links := linkPattern.FindAllString(mr.Message, -1)
+	score += float64(len(links))
+	if c.MaxLinks > 0 && len(links) > c.MaxLinks {
+		return score, true, fmt.Sprintf("message has %v links, more than the allowed %v", len(links), c.MaxLinks), nil
+	}
+
+	ratio := capsRatio(mr.Message)
+	score += ratio
+	if c.MaxCapsRatio > 0 && ratio > c.MaxCapsRatio {
+		return score, true, fmt.Sprintf("message is %.0f%% uppercase letters", ratio*100), nil
+	}
+
+	for _, re := range c.Blocklist {
+		if re.MatchString(mr.Message) {
+			return score, true, fmt.Sprintf("message matches blocked pattern %#v", re.String()), nil
+		}
+	}
+
+	return score, false, "", nil
+}
+
+func capsRatio(s string) float64 {
+	var letters, caps int
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.IsUpper(r) {
+			caps++
+		}
+	}
+
+	if letters == 0 {
+		return 0
+	}
+
+	return float64(caps) / float64(letters)
+}
+
+// SuffixCheck is the original, trivial spam gate: the message must end with
+// the given check string.
+type SuffixCheck struct{}
+
+func (c *SuffixCheck) Check(ctx context.Context, r *http.Request, mr *mailReq) (float64, bool, string, error) {
+	if mr.Check == "" {
+		return 0, true, "missing required check", nil
+	}
+
+	err := validateMessage(mr.Message, mr.Check)
+	if err != nil {
+		return 0, true, err.Error(), nil
+	}
+
+	return 0, false, "", nil
+}
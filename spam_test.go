@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func formRequest(t *testing.T, values url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/mail", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err := r.ParseForm()
+	require.Nil(t, err)
+	return r
+}
+
+func TestHoneypotCheck(t *testing.T) {
+	cases := []struct {
+		name   string
+		values url.Values
+		reject bool
+	}{
+		{"empty honeypot is allowed", url.Values{"website": {""}}, false},
+		{"filled honeypot is rejected", url.Values{"website": {"http://spam.example.org"}}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &HoneypotCheck{Field: "website"}
+			r := formRequest(t, tc.values)
+
+			_, reject, _, err := c.Check(r.Context(), r, &mailReq{})
+			require.Nil(t, err)
+			require.Equal(t, tc.reject, reject)
+		})
+	}
+}
+
+func TestCaptchaCheckVerifiesAgainstEndpoint(t *testing.T) {
+	cases := []struct {
+		name      string
+		token     string
+		succeeds  bool
+		wantReject bool
+	}{
+		{"missing token is rejected", "", false, true},
+		{"valid token is allowed", "good-token", true, false},
+		{"invalid token is rejected", "bad-token", false, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Nil(t, r.ParseForm())
+				if tc.succeeds {
+					w.Write([]byte(`{"success": true}`))
+				} else {
+					w.Write([]byte(`{"success": false}`))
+				}
+			}))
+			defer srv.Close()
+
+			c := &CaptchaCheck{Field: "h-captcha-response", VerifyURL: srv.URL, Secret: "shh"}
+			r := formRequest(t, url.Values{"h-captcha-response": {tc.token}})
+
+			_, reject, _, err := c.Check(r.Context(), r, &mailReq{})
+			require.Nil(t, err)
+			require.Equal(t, tc.wantReject, reject)
+		})
+	}
+}
+
+func TestHeuristicCheck(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     ConfigHeuristics
+		message string
+		reject  bool
+	}{
+		{"plain message is allowed", ConfigHeuristics{MaxLinks: 1, MaxCapsRatio: 0.9}, "hello there, nice site!", false},
+		{"too many links is rejected", ConfigHeuristics{MaxLinks: 1}, "http://a.example http://b.example http://c.example", true},
+		{"too many caps is rejected", ConfigHeuristics{MaxCapsRatio: 0.2}, "BUY NOW CHEAP PILLS", true},
+		{"blocklisted phrase is rejected", ConfigHeuristics{Blocklist: []string{"(?i)viagra"}}, "cheap Viagra here", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := newHeuristicCheck(tc.cfg)
+			require.Nil(t, err)
+
+			r := formRequest(t, url.Values{})
+			_, reject, _, err := c.Check(r.Context(), r, &mailReq{Message: tc.message})
+			require.Nil(t, err)
+			require.Equal(t, tc.reject, reject)
+		})
+	}
+}
+
+func TestSuffixCheck(t *testing.T) {
+	c := &SuffixCheck{}
+	r := formRequest(t, url.Values{})
+
+	_, reject, _, err := c.Check(r.Context(), r, &mailReq{Message: "hello check!", Check: "check!"})
+	require.Nil(t, err)
+	require.False(t, reject)
+
+	_, reject, _, err = c.Check(r.Context(), r, &mailReq{Message: "hello check!", Check: "nope"})
+	require.Nil(t, err)
+	require.True(t, reject)
+
+	_, reject, _, err = c.Check(r.Context(), r, &mailReq{Message: "hello check!"})
+	require.Nil(t, err)
+	require.True(t, reject)
+
+	_, reject, _, err = c.Check(r.Context(), r, &mailReq{Message: "hi", Check: "this-check-is-way-longer-than-the-message"})
+	require.Nil(t, err)
+	require.True(t, reject)
+}
+
+func TestSpamPipelineStopsAtFirstRejection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer srv.Close()
+
+	p := newSpamPipeline(
+		&HoneypotCheck{Field: "website"},
+		&CaptchaCheck{Field: "h-captcha-response", VerifyURL: srv.URL, Secret: "shh"},
+	)
+
+	r := formRequest(t, url.Values{"website": {"spam"}, "h-captcha-response": {"token"}})
+	_, reject, reason, err := p.Run(r.Context(), r, &mailReq{})
+	require.Nil(t, err)
+	require.True(t, reject)
+	require.Contains(t, reason, "honeypot")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errMessageNotFound is returned by Store.Get when no message with the
+// given ID is stored.
+var errMessageNotFound = errors.New("message not found")
+
+// MessageStatus tracks a StoredMessage through its delivery lifecycle.
+type MessageStatus string
+
+const (
+	StatusReceived   MessageStatus = "received"
+	StatusDelivered  MessageStatus = "delivered"
+	StatusDeadLetter MessageStatus = "dead-letter"
+)
+
+// StoredMessage is the persisted record of a mailReq plus its delivery
+// history, so a crash after a 200-OK no longer loses the message.
+type StoredMessage struct {
+	ID          string
+	Name        string
+	Email       string
+	Message     string
+	ReceivedAt  time.Time
+	ClientIP    string
+	Status      MessageStatus
+	Attempts    int
+	LastError   string
+	DeliveredAt time.Time
+}
+
+// Store persists StoredMessages across restarts.
+type Store interface {
+	Save(ctx context.Context, msg *StoredMessage) error
+	Get(ctx context.Context, id string) (*StoredMessage, error)
+	List(ctx context.Context) ([]*StoredMessage, error)
+	Delete(ctx context.Context, id string) error
+	Close() error
+}
+
+// newStoredMessage builds the initial record for a freshly received mailReq,
+// before any delivery attempt has been made.
+func newStoredMessage(mr *mailReq) *StoredMessage {
+	return &StoredMessage{
+		ID:         mr.ID,
+		Name:       mr.Name,
+		Email:      mr.Email,
+		Message:    mr.Message,
+		ClientIP:   mr.ClientIP,
+		ReceivedAt: time.Now(),
+		Status:     StatusReceived,
+	}
+}
+
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate message id err=%w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
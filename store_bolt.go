@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var messagesBucket = []byte("messages")
+
+// BoltStore persists messages in a single-file bbolt database.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func newBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt store path=%#v err=%w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(messagesBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create messages bucket err=%w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Save(ctx context.Context, msg *StoredMessage) error {
+	bt, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message err=%w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).Put([]byte(msg.ID), bt)
+	})
+}
+
+func (s *BoltStore) Get(ctx context.Context, id string) (*StoredMessage, error) {
+	var msg StoredMessage
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(messagesBucket).Get([]byte(id))
+		if bt == nil {
+			return errMessageNotFound
+		}
+		return json.Unmarshal(bt, &msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+func (s *BoltStore) List(ctx context.Context) ([]*StoredMessage, error) {
+	var msgs []*StoredMessage
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).ForEach(func(k, v []byte) error {
+			var msg StoredMessage
+			err := json.Unmarshal(v, &msg)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, &msg)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages err=%w", err)
+	}
+
+	return msgs, nil
+}
+
+func (s *BoltStore) Delete(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).Delete([]byte(id))
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore persists messages in a SQLite database, using the pure-Go
+// modernc.org/sqlite driver so contact keeps its cgo-free build.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite store path=%#v err=%w", path, err)
+	}
+
+	const schema = `
+CREATE TABLE IF NOT EXISTS messages (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	email        TEXT NOT NULL,
+	message      TEXT NOT NULL,
+	received_at  DATETIME NOT NULL,
+	client_ip    TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	attempts     INTEGER NOT NULL,
+	last_error   TEXT NOT NULL,
+	delivered_at DATETIME
+)`
+	_, err = db.Exec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create messages table err=%w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, msg *StoredMessage) error {
+	var deliveredAt *time.Time
+	if !msg.DeliveredAt.IsZero() {
+		deliveredAt = &msg.DeliveredAt
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO messages (id, name, email, message, received_at, client_ip, status, attempts, last_error, delivered_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+	status = excluded.status,
+	attempts = excluded.attempts,
+	last_error = excluded.last_error,
+	delivered_at = excluded.delivered_at`,
+		msg.ID, msg.Name, msg.Email, msg.Message, msg.ReceivedAt, msg.ClientIP,
+		msg.Status, msg.Attempts, msg.LastError, deliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save message id=%#v err=%w", msg.ID, err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id string) (*StoredMessage, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT id, name, email, message, received_at, client_ip, status, attempts, last_error, delivered_at
+FROM messages WHERE id = ?`, id)
+
+	msg, err := scanMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message id=%#v err=%w", id, err)
+	}
+
+	return msg, nil
+}
+
+func (s *SQLiteStore) List(ctx context.Context) ([]*StoredMessage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, name, email, message, received_at, client_ip, status, attempts, last_error, delivered_at
+FROM messages ORDER BY received_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages err=%w", err)
+	}
+	defer rows.Close()
+
+	var msgs []*StoredMessage
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message err=%w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, rows.Err()
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM messages WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete message id=%#v err=%w", id, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row rowScanner) (*StoredMessage, error) {
+	var msg StoredMessage
+	var deliveredAt sql.NullTime
+
+	err := row.Scan(
+		&msg.ID, &msg.Name, &msg.Email, &msg.Message, &msg.ReceivedAt, &msg.ClientIP,
+		&msg.Status, &msg.Attempts, &msg.LastError, &deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if deliveredAt.Valid {
+		msg.DeliveredAt = deliveredAt.Time
+	}
+
+	return &msg, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStoreSaveGetListDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.db")
+	store, err := newBoltStore(path)
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	msg := &StoredMessage{ID: "abc123", Name: "hans", Email: "hans@example.org", Message: "hi", Status: StatusReceived}
+
+	require.Nil(t, store.Save(ctx, msg))
+
+	got, err := store.Get(ctx, "abc123")
+	require.Nil(t, err)
+	require.Equal(t, msg.Email, got.Email)
+	require.Equal(t, StatusReceived, got.Status)
+
+	msg.Status = StatusDelivered
+	require.Nil(t, store.Save(ctx, msg))
+
+	got, err = store.Get(ctx, "abc123")
+	require.Nil(t, err)
+	require.Equal(t, StatusDelivered, got.Status)
+
+	all, err := store.List(ctx)
+	require.Nil(t, err)
+	require.Len(t, all, 1)
+
+	require.Nil(t, store.Delete(ctx, "abc123"))
+
+	_, err = store.Get(ctx, "abc123")
+	require.NotNil(t, err)
+}
+
+type fakeStore struct {
+	messages map[string]*StoredMessage
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{messages: make(map[string]*StoredMessage)}
+}
+
+func (s *fakeStore) Save(ctx context.Context, msg *StoredMessage) error {
+	s.messages[msg.ID] = msg
+	return nil
+}
+
+func (s *fakeStore) Get(ctx context.Context, id string) (*StoredMessage, error) {
+	msg, ok := s.messages[id]
+	if !ok {
+		return nil, errMessageNotFound
+	}
+	return msg, nil
+}
+
+func (s *fakeStore) List(ctx context.Context) ([]*StoredMessage, error) {
+	var msgs []*StoredMessage
+	for _, msg := range s.messages {
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, id string) error {
+	delete(s.messages, id)
+	return nil
+}
+
+func (s *fakeStore) Close() error { return nil }
+
+func TestStoreMailerMarksDeadLetterAfterMaxAttempts(t *testing.T) {
+	store := newFakeStore()
+	fm := &fakeMailer{failures: 10, done: make(chan struct{})}
+	m := NewStoreMailer(fm, store, 2)
+
+	mr := &mailReq{ID: "msg-1", Name: "hans", Email: "hans@example.org", Message: "hi"}
+
+	require.NotNil(t, m.Send(context.Background(), mr))
+	rec, err := store.Get(context.Background(), "msg-1")
+	require.Nil(t, err)
+	require.Equal(t, StatusReceived, rec.Status)
+	require.Equal(t, 1, rec.Attempts)
+
+	require.NotNil(t, m.Send(context.Background(), mr))
+	rec, err = store.Get(context.Background(), "msg-1")
+	require.Nil(t, err)
+	require.Equal(t, StatusDeadLetter, rec.Status)
+	require.Equal(t, 2, rec.Attempts)
+}
+
+func TestStoreMailerMarksDelivered(t *testing.T) {
+	store := newFakeStore()
+	fm := &fakeMailer{done: make(chan struct{})}
+	m := NewStoreMailer(fm, store, 3)
+
+	mr := &mailReq{ID: "msg-2", Name: "hans", Email: "hans@example.org", Message: "hi"}
+
+	require.Nil(t, m.Send(context.Background(), mr))
+	rec, err := store.Get(context.Background(), "msg-2")
+	require.Nil(t, err)
+	require.Equal(t, StatusDelivered, rec.Status)
+	require.False(t, rec.DeliveredAt.IsZero())
+}
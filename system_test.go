@@ -2,25 +2,26 @@ package main
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	purl "net/url"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
-	smtp "github.com/emersion/go-smtp"
 	"github.com/stretchr/testify/require"
 )
 
 type cmd struct {
 	cmd *exec.Cmd
 	in  string
+
+	mu     sync.Mutex
+	stderr bytes.Buffer
 }
 
 func newCmd() *cmd {
@@ -44,6 +45,14 @@ func (c *cmd) kill() {
 	}
 }
 
+// stderrString returns everything the process has written to stderr so far,
+// so tests can assert on log output instead of needing a live upstream.
+func (c *cmd) stderrString() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stderr.String()
+}
+
 func (c *cmd) runAsync(name string, args ...string) error {
 	c.cmd = exec.Command(name, args...)
 	if len(c.in) > 0 {
@@ -58,11 +67,16 @@ func (c *cmd) runAsync(name string, args ...string) error {
 		for {
 			ob := make([]byte, 1024)
 			bc, err := errPipe.Read(ob)
+			if bc > 0 {
+				c.mu.Lock()
+				c.stderr.Write(ob[:bc])
+				c.mu.Unlock()
+				log.Printf(">> stderr: %s\n", ob[:bc])
+			}
 			if err != nil {
 				log.Printf("stderr pipe failed err=%v", err)
 				return
 			}
-			log.Printf(">> stderr: %s\n", ob[:bc])
 		}
 	}()
 
@@ -115,84 +129,6 @@ func build(t *testing.T) {
 	require.Zero(t, status)
 }
 
-type TestSMTPBackend struct {
-	user, pass string
-	sessions   chan *TestSMTPSession
-	server     *smtp.Server
-}
-type TestSMTPSession struct {
-	lastData string
-}
-
-func (b *TestSMTPBackend) Login(state *smtp.ConnectionState, username, password string) (smtp.Session, error) {
-	if username != b.user || password != b.pass {
-		return nil, errors.New("Invalid username or password")
-	}
-	s := &TestSMTPSession{}
-	b.sessions <- s
-	return s, nil
-}
-
-func (b *TestSMTPBackend) Close() error {
-	return b.server.Close()
-}
-
-func (b *TestSMTPBackend) AnonymousLogin(state *smtp.ConnectionState) (smtp.Session, error) {
-	return nil, smtp.ErrAuthRequired
-}
-
-func (s *TestSMTPSession) Mail(from string, opts smtp.MailOptions) error {
-	log.Println(">> test SMTP Mail from:", from)
-	return nil
-}
-
-func (s *TestSMTPSession) Rcpt(to string) error {
-	log.Println(">> test SMTP Rcpt to:", to)
-	return nil
-}
-
-func (s *TestSMTPSession) Data(r io.Reader) error {
-	b, err := io.ReadAll(r)
-	if err != nil {
-		return err
-	}
-
-	log.Println(">> test SMTP Data:", string(b))
-	s.lastData = string(b)
-	return nil
-}
-func (s *TestSMTPSession) Reset() {}
-
-func (s *TestSMTPSession) Logout() error {
-	return nil
-}
-
-func startSMTPServer(t *testing.T) *TestSMTPBackend {
-	sb := &TestSMTPBackend{
-		user:     "test",
-		pass:     "abc123",
-		sessions: make(chan *TestSMTPSession, 1),
-	}
-	sb.server = smtp.NewServer(sb)
-	sb.server.Addr = ":1025"
-	sb.server.Domain = "localhost"
-	sb.server.ReadTimeout = 10 * time.Second
-	sb.server.WriteTimeout = 10 * time.Second
-	sb.server.MaxMessageBytes = 1024 * 1024
-	sb.server.MaxRecipients = 50
-	sb.server.AllowInsecureAuth = true
-
-	go func() {
-		log.Printf("starting test SMTP server\n")
-		err := sb.server.ListenAndServe()
-		if err != nil {
-			log.Printf("Test SMTP server failed err=%v\n", err)
-		}
-	}()
-
-	return sb
-}
-
 func submitTestForm(url, name, email, message, check string) (*http.Response, error) {
 	fd := purl.Values{}
 	fd.Add("name", name)
@@ -202,10 +138,11 @@ func submitTestForm(url, name, email, message, check string) (*http.Response, er
 	return http.PostForm(url, fd)
 }
 
+// TestSystem runs the built binary against test-data/test-cfg.yml, which
+// configures email.backend: null, so the test asserts against the
+// NullMailer's log line instead of needing a live SMTP server.
 func TestSystem(t *testing.T) {
 	build(t)
-	sb := startSMTPServer(t)
-	defer sb.Close()
 
 	var err error
 
@@ -228,13 +165,13 @@ func TestSystem(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, resp.StatusCode, 200)
 
-	log.Print("waiting for sessions to be available")
-	ts := <-sb.sessions
+	time.Sleep(100 * time.Millisecond)
+	out := cmd.stderrString()
 
-	require.Contains(t, ts.lastData, fmt.Sprintf("Subject: contact from %v", testName))
-	require.Contains(t, ts.lastData, fmt.Sprintf("Name: %v", testName))
-	require.Contains(t, ts.lastData, fmt.Sprintf("Email: %v", testEmail))
-	require.Contains(t, ts.lastData, "hello there")
+	require.Contains(t, out, fmt.Sprintf("contact from %v", testName))
+	require.Contains(t, out, fmt.Sprintf("Name: %v", testName))
+	require.Contains(t, out, fmt.Sprintf("Email: %v", testEmail))
+	require.Contains(t, out, "hello there")
 
 	resp, err = submitTestForm(
 		"http://localhost:5151/mail",